@@ -0,0 +1,198 @@
+package solar
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/uol/logh"
+)
+
+/**
+* Contains the collections administration API for the solr service.
+* @author rnojiri
+**/
+
+const (
+	cParamNumShards         string = "numShards"
+	cParamReplicationFactor string = "replicationFactor"
+	cParamMaxShardsPerNode  string = "maxShardsPerNode"
+	cParamConfigName        string = "collection.configName"
+	cParamRouterName        string = "router.name"
+	cParamRouterField       string = "router.field"
+	cParamShards            string = "shards"
+)
+
+// CollectionConfig - describes the parameters used to create a collection
+type CollectionConfig struct {
+	NumShards         int
+	ReplicationFactor int
+	MaxShardsPerNode  int
+	ConfigName        string
+	RouterName        string
+	RouterField       string
+	ShardsList        []string
+}
+
+// toParams - converts this configuration to the solr collections API parameters
+func (c *CollectionConfig) toParams() *url.Values {
+
+	params := &url.Values{}
+
+	if c.NumShards > 0 {
+		params.Set(cParamNumShards, strconv.Itoa(c.NumShards))
+	}
+
+	if c.ReplicationFactor > 0 {
+		params.Set(cParamReplicationFactor, strconv.Itoa(c.ReplicationFactor))
+	}
+
+	if c.MaxShardsPerNode > 0 {
+		params.Set(cParamMaxShardsPerNode, strconv.Itoa(c.MaxShardsPerNode))
+	}
+
+	if c.ConfigName != cEmpty {
+		params.Set(cParamConfigName, c.ConfigName)
+	}
+
+	if c.RouterName != cEmpty {
+		params.Set(cParamRouterName, c.RouterName)
+	}
+
+	if c.RouterField != cEmpty {
+		params.Set(cParamRouterField, c.RouterField)
+	}
+
+	if len(c.ShardsList) > 0 {
+		params.Set(cParamShards, strings.Join(c.ShardsList, ","))
+	}
+
+	return params
+}
+
+// CreateCollection - creates a new collection using the given configuration
+func (ss *SolrService) CreateCollection(name string, cfg CollectionConfig) error {
+
+	defer ss.recoverFromFailure()
+
+	if name == cEmpty {
+		return errors.New("collection name not informed")
+	}
+
+	err := ss.solrCollectionsAdmin.Create(name, cfg.toParams())
+	if err != nil {
+		if logh.ErrorEnabled {
+			ss.loggers.Error().Err(err).Msg("error creating collection")
+		}
+		return err
+	}
+
+	ss.solrInterfaceCache.Delete(name)
+
+	return nil
+}
+
+// DeleteCollection - deletes an existing collection
+func (ss *SolrService) DeleteCollection(name string) error {
+
+	defer ss.recoverFromFailure()
+
+	if name == cEmpty {
+		return errors.New("collection name not informed")
+	}
+
+	err := ss.solrCollectionsAdmin.Delete(name)
+	if err != nil {
+		if logh.ErrorEnabled {
+			ss.loggers.Error().Err(err).Msg("error deleting collection")
+		}
+		return err
+	}
+
+	ss.solrInterfaceCache.Delete(name)
+
+	return nil
+}
+
+// ReloadCollection - reloads an existing collection
+func (ss *SolrService) ReloadCollection(name string) error {
+
+	defer ss.recoverFromFailure()
+
+	if name == cEmpty {
+		return errors.New("collection name not informed")
+	}
+
+	err := ss.solrCollectionsAdmin.Reload(name)
+	if err != nil {
+		if logh.ErrorEnabled {
+			ss.loggers.Error().Err(err).Msg("error reloading collection")
+		}
+		return err
+	}
+
+	ss.solrInterfaceCache.Delete(name)
+
+	return nil
+}
+
+// ListCollections - lists all existing collections
+func (ss *SolrService) ListCollections() ([]string, error) {
+
+	defer ss.recoverFromFailure()
+
+	collections, err := ss.solrCollectionsAdmin.List()
+	if err != nil {
+		if logh.ErrorEnabled {
+			ss.loggers.Error().Err(err).Msg("error listing collections")
+		}
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// CreateAlias - creates or updates an alias pointing to the given target collection
+func (ss *SolrService) CreateAlias(alias, target string) error {
+
+	defer ss.recoverFromFailure()
+
+	if alias == cEmpty {
+		return errors.New("alias name not informed")
+	}
+
+	if target == cEmpty {
+		return errors.New("target collection not informed")
+	}
+
+	err := ss.solrCollectionsAdmin.CreateAlias(alias, target)
+	if err != nil {
+		if logh.ErrorEnabled {
+			ss.loggers.Error().Err(err).Msg("error creating alias")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// DeleteAlias - deletes an existing alias
+func (ss *SolrService) DeleteAlias(alias string) error {
+
+	defer ss.recoverFromFailure()
+
+	if alias == cEmpty {
+		return errors.New("alias name not informed")
+	}
+
+	err := ss.solrCollectionsAdmin.DeleteAlias(alias)
+	if err != nil {
+		if logh.ErrorEnabled {
+			ss.loggers.Error().Err(err).Msg("error deleting alias")
+		}
+		return err
+	}
+
+	return nil
+}
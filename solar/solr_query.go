@@ -0,0 +1,317 @@
+package solar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/uol/go-solr/solr"
+	"github.com/uol/logh"
+)
+
+/**
+* Contains the search/query API for the solr service.
+* @author rnojiri
+**/
+
+const (
+	cStar        string = "*"
+	cParamQ      string = "q"
+	cParamFq     string = "fq"
+	cParamFl     string = "fl"
+	cParamSort   string = "sort"
+	cParamStart  string = "start"
+	cParamRows   string = "rows"
+	cParamCursor string = "cursorMark"
+	cParamDebug  string = "debugQuery"
+
+	cParamFacet      string = "facet"
+	cParamFacetField string = "facet.field"
+	cParamFacetRange string = "facet.range"
+	cParamFacetPivot string = "facet.pivot"
+
+	cParamGroup      string = "group"
+	cParamGroupField string = "group.field"
+	cParamGroupLimit string = "group.limit"
+
+	cParamHL         string = "hl"
+	cParamHLFields   string = "hl.fl"
+	cParamHLSnippets string = "hl.snippets"
+	cParamHLFragSize string = "hl.fragsize"
+
+	cDefaultRows int = 10
+)
+
+// FacetRange - describes a Solr range facet
+type FacetRange struct {
+	Field string
+	Start string
+	End   string
+	Gap   string
+}
+
+// FacetConfig - describes the facet parameters of a query
+type FacetConfig struct {
+	Fields []string
+	Ranges []FacetRange
+	Pivots []string
+}
+
+// GroupConfig - describes the grouping parameters of a query
+type GroupConfig struct {
+	Fields []string
+	Limit  int
+}
+
+// HighlightConfig - describes the highlighting parameters of a query
+type HighlightConfig struct {
+	Fields   []string
+	Snippets int
+	FragSize int
+}
+
+// SolrQuery - describes a search query to be executed against a collection
+type SolrQuery struct {
+	Q           string
+	Fq          []string
+	Fl          []string
+	Sort        string
+	Start       int
+	// Rows - the page size. A nil Rows leaves the solr default in place; use IntPtr(0)
+	// to request rows=0, as a facet-only query typically does.
+	Rows        *int
+	Facet       *FacetConfig
+	Group       *GroupConfig
+	Highlight   *HighlightConfig
+	Debug       bool
+	CursorMark  string
+	ExtraParams url.Values
+}
+
+// IntPtr - returns a pointer to the given int, for use with SolrQuery.Rows
+func IntPtr(i int) *int {
+
+	return &i
+}
+
+// toParams - converts this query to the solr request parameters
+func (q *SolrQuery) toParams() *url.Values {
+
+	params := &url.Values{}
+
+	if q.Q != cEmpty {
+		params.Set(cParamQ, q.Q)
+	} else {
+		params.Set(cParamQ, cStar+":"+cStar)
+	}
+
+	for _, fq := range q.Fq {
+		params.Add(cParamFq, fq)
+	}
+
+	if len(q.Fl) > 0 {
+		params.Set(cParamFl, strings.Join(q.Fl, ","))
+	}
+
+	if q.Sort != cEmpty {
+		params.Set(cParamSort, q.Sort)
+	}
+
+	params.Set(cParamStart, strconv.Itoa(q.Start))
+
+	if q.Rows != nil {
+		params.Set(cParamRows, strconv.Itoa(*q.Rows))
+	}
+
+	if q.CursorMark != cEmpty {
+		params.Set(cParamCursor, q.CursorMark)
+	}
+
+	if q.Debug {
+		params.Set(cParamDebug, cTrue)
+	}
+
+	if q.Facet != nil {
+
+		params.Set(cParamFacet, cTrue)
+
+		for _, field := range q.Facet.Fields {
+			params.Add(cParamFacetField, field)
+		}
+
+		for _, r := range q.Facet.Ranges {
+			params.Add(cParamFacetRange, r.Field)
+			params.Set(fmt.Sprintf("f.%s.facet.range.start", r.Field), r.Start)
+			params.Set(fmt.Sprintf("f.%s.facet.range.end", r.Field), r.End)
+			params.Set(fmt.Sprintf("f.%s.facet.range.gap", r.Field), r.Gap)
+		}
+
+		for _, pivot := range q.Facet.Pivots {
+			params.Add(cParamFacetPivot, pivot)
+		}
+	}
+
+	if q.Group != nil {
+
+		params.Set(cParamGroup, cTrue)
+
+		for _, field := range q.Group.Fields {
+			params.Add(cParamGroupField, field)
+		}
+
+		if q.Group.Limit > 0 {
+			params.Set(cParamGroupLimit, strconv.Itoa(q.Group.Limit))
+		}
+	}
+
+	if q.Highlight != nil {
+
+		params.Set(cParamHL, cTrue)
+
+		if len(q.Highlight.Fields) > 0 {
+			params.Set(cParamHLFields, strings.Join(q.Highlight.Fields, ","))
+		}
+
+		if q.Highlight.Snippets > 0 {
+			params.Set(cParamHLSnippets, strconv.Itoa(q.Highlight.Snippets))
+		}
+
+		if q.Highlight.FragSize > 0 {
+			params.Set(cParamHLFragSize, strconv.Itoa(q.Highlight.FragSize))
+		}
+	}
+
+	for name, values := range q.ExtraParams {
+		for _, value := range values {
+			params.Add(name, value)
+		}
+	}
+
+	return params
+}
+
+// solrSearchEnvelope - mirrors the relevant parts of solr's search response json
+type solrSearchEnvelope struct {
+	Response struct {
+		NumFound int                      `json:"numFound"`
+		Start    int                      `json:"start"`
+		Docs     []map[string]interface{} `json:"docs"`
+	} `json:"response"`
+	FacetCounts    map[string]interface{} `json:"facet_counts,omitempty"`
+	Highlighting   map[string]interface{} `json:"highlighting,omitempty"`
+	Debug          map[string]interface{} `json:"debug,omitempty"`
+	NextCursorMark string                 `json:"nextCursorMark,omitempty"`
+}
+
+// SearchResult - the typed result of a search query
+type SearchResult struct {
+	NumFound       int
+	Start          int
+	Docs           []map[string]interface{}
+	FacetCounts    map[string]interface{}
+	Highlighting   map[string]interface{}
+	Debug          map[string]interface{}
+	NextCursorMark string
+}
+
+// newSearchResult - decodes the solr interface's raw json response into a typed search result.
+// solr.SolrResult only exposes the fields go-solr itself understands, so nextCursorMark and
+// the other top-level fields this package cares about are decoded straight from its Raw bytes
+// instead of round-tripping through the typed struct.
+func newSearchResult(result *solr.SolrResult) (*SearchResult, error) {
+
+	envelope := &solrSearchEnvelope{}
+	if err := json.Unmarshal(result.Raw, envelope); err != nil {
+		return nil, err
+	}
+
+	return &SearchResult{
+		NumFound:       envelope.Response.NumFound,
+		Start:          envelope.Response.Start,
+		Docs:           envelope.Response.Docs,
+		FacetCounts:    envelope.FacetCounts,
+		Highlighting:   envelope.Highlighting,
+		Debug:          envelope.Debug,
+		NextCursorMark: envelope.NextCursorMark,
+	}, nil
+}
+
+// Search - searches a collection using the given query
+func (ss *SolrService) Search(collection string, q *SolrQuery) (*SearchResult, error) {
+
+	defer ss.recoverFromFailure()
+
+	if q == nil {
+		return nil, errors.New("query is null")
+	}
+
+	si, err := ss.getSolrInterface(collection)
+	if err != nil {
+		if logh.ErrorEnabled {
+			ss.loggers.Error().Err(err).Msg("error getting solr interface")
+		}
+		return nil, err
+	}
+
+	result, err := si.Select(q.toParams())
+	if err != nil {
+		if logh.ErrorEnabled {
+			ss.loggers.Error().Err(err).Msg("error executing search")
+		}
+		return nil, err
+	}
+
+	return newSearchResult(result)
+}
+
+// SearchCursor - streams all documents matching the query using solr's cursorMark pagination,
+// calling handler for every document found
+func (ss *SolrService) SearchCursor(collection string, q *SolrQuery, handler func(doc map[string]interface{}) error) error {
+
+	defer ss.recoverFromFailure()
+
+	if q == nil {
+		return errors.New("query is null")
+	}
+
+	if q.Sort == cEmpty {
+		return errors.New("a sort clause is required for cursor based pagination")
+	}
+
+	// cursorQuery - a local copy so the cursor's Rows/Start/CursorMark bookkeeping doesn't
+	// leak back into the caller's query
+	cursorQuery := *q
+
+	if cursorQuery.Rows == nil || *cursorQuery.Rows <= 0 {
+		cursorQuery.Rows = IntPtr(cDefaultRows)
+	}
+
+	// solr rejects cursorMark requests unless start=0
+	cursorQuery.Start = 0
+
+	cursorMark := cStar
+	cursorQuery.CursorMark = cursorMark
+
+	for {
+		result, err := ss.Search(collection, &cursorQuery)
+		if err != nil {
+			return err
+		}
+
+		for _, doc := range result.Docs {
+			if err := handler(doc); err != nil {
+				return err
+			}
+		}
+
+		if result.NextCursorMark == cEmpty || result.NextCursorMark == cursorMark {
+			return nil
+		}
+
+		cursorMark = result.NextCursorMark
+		cursorQuery.CursorMark = cursorMark
+	}
+}
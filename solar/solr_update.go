@@ -0,0 +1,190 @@
+package solar
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/uol/go-solr/solr"
+	"github.com/uol/logh"
+)
+
+/**
+* Contains the atomic document update API for the solr service.
+* @author rnojiri
+**/
+
+// ErrVersionConflict - returned when an atomic update is rejected because the document's
+// stored version does not match the informed IfVersion
+var ErrVersionConflict = errors.New("version conflict")
+
+// AtomicModifier - a solr atomic update modifier
+type AtomicModifier string
+
+const (
+	// AtomicSet - sets the field to the given value, removing it if the value is null
+	AtomicSet AtomicModifier = "set"
+
+	// AtomicAdd - adds the value to a multivalued field
+	AtomicAdd AtomicModifier = "add"
+
+	// AtomicAddDistinct - adds the value to a multivalued field, only if not already present
+	AtomicAddDistinct AtomicModifier = "add-distinct"
+
+	// AtomicRemove - removes the value from a multivalued field
+	AtomicRemove AtomicModifier = "remove"
+
+	// AtomicRemoveRegex - removes values matching the regex from a multivalued field
+	AtomicRemoveRegex AtomicModifier = "removeregex"
+
+	// AtomicInc - increments the field by the given value
+	AtomicInc AtomicModifier = "inc"
+)
+
+const cVersionField string = "_version_"
+
+// AtomicOp - a single atomic modification to apply to a document's field
+type AtomicOp struct {
+	Modifier AtomicModifier
+	Value    interface{}
+}
+
+// toModifierMap - converts this operation to solr's atomic update json shape
+func (op AtomicOp) toModifierMap() map[string]interface{} {
+
+	return map[string]interface{}{
+		string(op.Modifier): op.Value,
+	}
+}
+
+// isVersionConflict - checks if the given error is a solr optimistic concurrency conflict.
+//
+// go-solr's SolrInterface.Add does not expose the HTTP status code of a failed request, only
+// the Solr response body wrapped in a plain error, so the 409 has to be inferred from the
+// message text. Solr reports an optimistic concurrency failure with "version conflict" in the
+// body; a bare "409" is too easily confused with a document id, a _version_ number or a doc
+// count, so a status token is required alongside it instead.
+func isVersionConflict(err error) bool {
+
+	if err == nil {
+		return false
+	}
+
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "version conflict") {
+		return true
+	}
+
+	return strings.Contains(msg, "409") && strings.Contains(msg, "conflict")
+}
+
+// buildUpdateDocument - builds the atomic update document for the given id and modifiers
+func buildUpdateDocument(id string, mods map[string]AtomicOp, ifVersion int64) solr.Document {
+
+	doc := solr.Document{
+		"id": id,
+	}
+
+	for field, op := range mods {
+		doc[field] = op.toModifierMap()
+	}
+
+	if ifVersion != 0 {
+		doc[cVersionField] = ifVersion
+	}
+
+	return doc
+}
+
+// UpdateDocument - applies atomic (partial) modifications to a single document,
+// optionally enforcing optimistic concurrency through ifVersion (0 means not enforced)
+func (ss *SolrService) UpdateDocument(collection string, commit bool, id string, mods map[string]AtomicOp, ifVersion ...int64) error {
+
+	defer ss.recoverFromFailure()
+
+	if id == cEmpty {
+		return errors.New("document id not informed, no document will be updated")
+	}
+
+	if len(mods) == 0 {
+		return errors.New("no modifications informed, no document will be updated")
+	}
+
+	var version int64
+	if len(ifVersion) > 0 {
+		version = ifVersion[0]
+	}
+
+	si, err := ss.getSolrInterface(collection)
+	if err != nil {
+		if logh.ErrorEnabled {
+			ss.loggers.Error().Err(err).Msg("error getting solr interface")
+		}
+		return err
+	}
+
+	params := &url.Values{}
+	if commit {
+		params.Add(cCommit, cTrue)
+	}
+
+	doc := buildUpdateDocument(id, mods, version)
+
+	_, err = si.Add([]solr.Document{doc}, 0, params)
+	if err != nil {
+		if isVersionConflict(err) {
+			return ErrVersionConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+// DocumentUpdate - describes a single document's atomic modifications for a batch update
+type DocumentUpdate struct {
+	ID        string
+	Mods      map[string]AtomicOp
+	IfVersion int64
+}
+
+// UpdateDocuments - applies atomic (partial) modifications to one or more documents in a single request
+func (ss *SolrService) UpdateDocuments(collection string, commit bool, updates ...DocumentUpdate) error {
+
+	defer ss.recoverFromFailure()
+
+	if len(updates) == 0 {
+		return errors.New("no updates informed, no document will be updated")
+	}
+
+	si, err := ss.getSolrInterface(collection)
+	if err != nil {
+		if logh.ErrorEnabled {
+			ss.loggers.Error().Err(err).Msg("error getting solr interface")
+		}
+		return err
+	}
+
+	params := &url.Values{}
+	if commit {
+		params.Add(cCommit, cTrue)
+	}
+
+	docs := make([]solr.Document, len(updates))
+	for i, u := range updates {
+		if u.ID == cEmpty {
+			return errors.New("document id not informed, no document will be updated")
+		}
+		docs[i] = buildUpdateDocument(u.ID, u.Mods, u.IfVersion)
+	}
+
+	_, err = si.Add(docs, 0, params)
+	if err != nil {
+		if isVersionConflict(err) {
+			return ErrVersionConflict
+		}
+		return err
+	}
+
+	return nil
+}
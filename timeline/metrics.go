@@ -0,0 +1,174 @@
+package timeline
+
+import (
+	"expvar"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+/**
+* Observability hooks for the batch transfer loop.
+* @author rnojiri
+**/
+
+// MetricsSink - receives the observability events emitted by a transport's batch loop
+type MetricsSink interface {
+
+	// IncCounter - increments a counter metric by v
+	IncCounter(name string, tags map[string]string, v int64)
+
+	// ObserveHistogram - records an observation for a histogram metric
+	ObserveHistogram(name string, tags map[string]string, v float64)
+}
+
+// noopMetricsSink - a MetricsSink that discards every event, used when none is configured
+type noopMetricsSink struct{}
+
+func (noopMetricsSink) IncCounter(name string, tags map[string]string, v int64)         {}
+func (noopMetricsSink) ObserveHistogram(name string, tags map[string]string, v float64) {}
+
+// defaultMetricsSink - the sink used when no MetricsSink was configured
+var defaultMetricsSink MetricsSink = noopMetricsSink{}
+
+// metricsSink - returns the configured sink, falling back to the no-op default
+func (t *transportCore) metricsSink() MetricsSink {
+
+	if t.metrics == nil {
+		return defaultMetricsSink
+	}
+
+	return t.metrics
+}
+
+// metricKey - builds a flat, deterministic expvar key from a metric name and its tags
+func metricKey(prefix, name string, tags map[string]string) string {
+
+	key := prefix + name
+
+	tagNames := make([]string, 0, len(tags))
+	for k := range tags {
+		tagNames = append(tagNames, k)
+	}
+	sort.Strings(tagNames)
+
+	for _, k := range tagNames {
+		key += fmt.Sprintf(",%s=%s", k, tags[k])
+	}
+
+	return key
+}
+
+// expvarHistogram - a minimal running-average histogram exposed as an expvar
+type expvarHistogram struct {
+	mu    sync.Mutex
+	count int64
+	sum   float64
+}
+
+// String - implements expvar.Var, exposing the current average
+func (h *expvarHistogram) String() string {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.count == 0 {
+		return "0"
+	}
+
+	return fmt.Sprintf("%.4f", h.sum/float64(h.count))
+}
+
+// observe - records a new value
+func (h *expvarHistogram) observe(v float64) {
+
+	h.mu.Lock()
+	h.count++
+	h.sum += v
+	h.mu.Unlock()
+}
+
+// ExpvarMetricsSink - a MetricsSink backed by the standard library's expvar package, so metrics
+// can be scraped without pulling a dedicated metrics client into the module
+type ExpvarMetricsSink struct {
+	prefix     string
+	counters   sync.Map
+	histograms sync.Map
+}
+
+// NewExpvarMetricsSink - creates a new sink publishing every metric under the given prefix
+func NewExpvarMetricsSink(prefix string) *ExpvarMetricsSink {
+
+	return &ExpvarMetricsSink{
+		prefix: prefix,
+	}
+}
+
+// IncCounter - implements MetricsSink
+func (s *ExpvarMetricsSink) IncCounter(name string, tags map[string]string, v int64) {
+
+	key := metricKey(s.prefix, name, tags)
+
+	raw, loaded := s.counters.LoadOrStore(key, new(expvar.Int))
+	counter := raw.(*expvar.Int)
+	if !loaded {
+		expvar.Publish(key, counter)
+	}
+
+	counter.Add(v)
+}
+
+// ObserveHistogram - implements MetricsSink
+func (s *ExpvarMetricsSink) ObserveHistogram(name string, tags map[string]string, v float64) {
+
+	key := metricKey(s.prefix, name, tags)
+
+	raw, loaded := s.histograms.LoadOrStore(key, &expvarHistogram{})
+	histogram := raw.(*expvarHistogram)
+	if !loaded {
+		expvar.Publish(key, histogram)
+	}
+
+	histogram.observe(v)
+}
+
+// TransportStats - a point in time snapshot of a transport's in-flight counters
+type TransportStats struct {
+	ChannelOccupancy    int
+	ChannelCapacity     int
+	ConsecutiveFailures int
+	CircuitOpen         bool
+}
+
+// Stats - returns a snapshot of this transport's current in-flight counters
+func (t *transportCore) Stats() TransportStats {
+
+	stats := TransportStats{
+		ChannelOccupancy: len(t.pointChannel),
+		ChannelCapacity:  cap(t.pointChannel),
+	}
+
+	if t.breaker != nil {
+		t.breaker.mu.Lock()
+		stats.ConsecutiveFailures = t.breaker.consecutiveFailures
+		stats.CircuitOpen = t.breaker.state != circuitClosed
+		t.breaker.mu.Unlock()
+	}
+
+	return stats
+}
+
+// errorClass - classifies an error for per-error-class counters
+func errorClass(err error) string {
+
+	if err == nil {
+		return ""
+	}
+
+	switch err.(type) {
+	case *ErrSendTimeout:
+		return "send_timeout"
+	default:
+		return fmt.Sprintf("%T", err)
+	}
+}
@@ -0,0 +1,220 @@
+package timeline
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+/**
+* Retry, circuit breaking and dead letter handling for the batch transfer loop.
+* @author rnojiri
+**/
+
+// errCircuitOpen - passed to the dead letter handler when a batch is dropped because the circuit breaker is open
+var errCircuitOpen = errors.New("circuit breaker is open, batch was not sent")
+
+// RetryPolicy - configures the retry behaviour used when a batch transfer fails
+type RetryPolicy struct {
+
+	// MaxRetries - maximum number of retries after the initial attempt
+	MaxRetries int
+
+	// InitialBackoff - backoff used after the first failure
+	InitialBackoff time.Duration
+
+	// MaxBackoff - upper bound for the backoff, regardless of the attempt number
+	MaxBackoff time.Duration
+
+	// Multiplier - factor applied to the backoff after each failed attempt
+	Multiplier float64
+
+	// Jitter - when true, the actual sleep is a random duration between zero and the computed backoff
+	Jitter bool
+
+	// CircuitBreakerThreshold - consecutive failures required to open the circuit. Zero disables it.
+	CircuitBreakerThreshold int
+
+	// CircuitBreakerCooldown - how long the circuit stays open before allowing a single probe batch
+	CircuitBreakerCooldown time.Duration
+}
+
+// DeadLetterHandler - invoked with the dropped batch and the terminal error, when retries are
+// exhausted or the circuit breaker is open
+type DeadLetterHandler func(points []interface{}, err error)
+
+// circuitState - the state of a circuitBreaker
+type circuitState uint8
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker - a simple consecutive-failures circuit breaker
+type circuitBreaker struct {
+	mu                  sync.Mutex
+	state               circuitState
+	consecutiveFailures int
+	threshold           int
+	cooldown            time.Duration
+	openedAt            time.Time
+}
+
+// newCircuitBreaker - creates a new circuit breaker, a threshold of zero or less disables tripping
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// allow - checks if a batch may be sent right now, moving an open circuit to half-open once the
+// cooldown has elapsed
+func (cb *circuitBreaker) allow() bool {
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state != circuitOpen {
+		return true
+	}
+
+	if time.Since(cb.openedAt) < cb.cooldown {
+		return false
+	}
+
+	cb.state = circuitHalfOpen
+
+	return true
+}
+
+// onSuccess - registers a successful batch transfer
+func (cb *circuitBreaker) onSuccess() {
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.consecutiveFailures = 0
+	cb.state = circuitClosed
+}
+
+// onFailure - registers a failed batch transfer, tripping the breaker when the threshold is reached
+func (cb *circuitBreaker) onFailure() circuitState {
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == circuitHalfOpen {
+		cb.state = circuitOpen
+		cb.openedAt = time.Now()
+		return cb.state
+	}
+
+	cb.consecutiveFailures++
+
+	if cb.threshold > 0 && cb.consecutiveFailures >= cb.threshold {
+		cb.state = circuitOpen
+		cb.openedAt = time.Now()
+	}
+
+	return cb.state
+}
+
+// nextBackoff - computes the sleep for the given current base backoff (capped at MaxBackoff, and
+// full-jittered when Jitter is set) and returns the next attempt's base backoff
+func (p *RetryPolicy) nextBackoff(current time.Duration) (sleep time.Duration, next time.Duration) {
+
+	capped := current
+	if p.MaxBackoff > 0 && capped > p.MaxBackoff {
+		capped = p.MaxBackoff
+	}
+
+	sleep = capped
+	if p.Jitter && capped > 0 {
+		sleep = time.Duration(rand.Int63n(int64(capped) + 1))
+	}
+
+	next = time.Duration(float64(capped) * p.Multiplier)
+	if p.MaxBackoff > 0 && next > p.MaxBackoff {
+		next = p.MaxBackoff
+	}
+
+	return sleep, next
+}
+
+// sendBatch - sends a batch applying the retry policy and the circuit breaker, invoking the
+// dead letter handler when the batch cannot be delivered
+func (t *transportCore) sendBatch(points []interface{}, lf []zapcore.Field) {
+
+	sink := t.metricsSink()
+
+	if t.breaker == nil && t.retryPolicy.CircuitBreakerThreshold > 0 {
+		t.breaker = newCircuitBreaker(t.retryPolicy.CircuitBreakerThreshold, t.retryPolicy.CircuitBreakerCooldown)
+	}
+
+	if t.breaker != nil && !t.breaker.allow() {
+		t.logger.Info("circuit breaker is open, dropping batch", lf...)
+		sink.IncCounter("timeline.circuit.dropped", nil, 1)
+		t.deadLetter(points, errCircuitOpen)
+		return
+	}
+
+	var err error
+	backoff := t.retryPolicy.InitialBackoff
+
+	for attempt := 0; attempt <= t.retryPolicy.MaxRetries; attempt++ {
+
+		start := time.Now()
+		err = t.transport.TransferData(points)
+		sink.ObserveHistogram("timeline.transfer.latency_ms", nil, float64(time.Since(start).Milliseconds()))
+
+		if err == nil {
+			if t.breaker != nil {
+				t.breaker.onSuccess()
+			}
+			t.logger.Info(fmt.Sprintf("batch of %d points were sent!", len(points)), lf...)
+			return
+		}
+
+		sink.IncCounter("timeline.transfer.errors", map[string]string{"class": errorClass(err)}, 1)
+
+		if !t.transport.IsRetriable(err) || attempt == t.retryPolicy.MaxRetries {
+			break
+		}
+
+		sleep, next := t.retryPolicy.nextBackoff(backoff)
+		backoff = next
+
+		sink.IncCounter("timeline.transfer.retries", nil, 1)
+
+		t.logger.Info(fmt.Sprintf("retrying batch transfer (attempt %d/%d) in %s: %s", attempt+1, t.retryPolicy.MaxRetries, sleep, err.Error()), lf...)
+
+		time.Sleep(sleep)
+	}
+
+	if t.breaker != nil {
+		state := t.breaker.onFailure()
+		if state == circuitOpen {
+			t.logger.Error("circuit breaker opened after consecutive failures", lf...)
+		}
+	}
+
+	t.logger.Error(fmt.Sprintf("giving up on batch of %d points: %s", len(points), err.Error()), lf...)
+
+	t.deadLetter(points, err)
+}
+
+// deadLetter - forwards a batch that could not be delivered to the configured handler, if any
+func (t *transportCore) deadLetter(points []interface{}, err error) {
+
+	if t.deadLetterHandler != nil {
+		t.deadLetterHandler(points, err)
+	}
+}
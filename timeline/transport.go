@@ -1,8 +1,8 @@
 package timeline
 
 import (
+	"errors"
 	"fmt"
-	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -27,6 +27,9 @@ type Transport interface {
 	// Send - send a new point
 	DataChannel() chan<- interface{}
 
+	// SendWithDeadline - sends a new point, giving up if the deadline elapses before it can be queued
+	SendWithDeadline(item interface{}, deadline time.Time) error
+
 	// ConfigureBackend - configures the backend
 	ConfigureBackend(backend *Backend) error
 
@@ -42,6 +45,9 @@ type Transport interface {
 	// MatchType - checks if this transport implementation matches the given type
 	MatchType(tt transportType) bool
 
+	// IsRetriable - checks if a TransferData error should be retried or is terminal
+	IsRetriable(err error) bool
+
 	// DataChannelItemToFlattenedPoint - converts the data channel item to the flattened point one
 	DataChannelItemToFlattenedPoint(operation FlatOperation, item interface{}) (*FlattenerPoint, error)
 
@@ -51,11 +57,17 @@ type Transport interface {
 
 // transportCore - implements a default transport behaviour
 type transportCore struct {
-	transport              Transport
-	batchSendInterval      time.Duration
-	pointChannel           chan interface{}
-	logger                 *zap.Logger
-	dataTransferInProgress uint32
+	transport         Transport
+	batchSendInterval time.Duration
+	maxBatchSize      int
+	maxBatchBytes     int
+	pointChannel      chan interface{}
+	closeChan         chan struct{}
+	logger            *zap.Logger
+	retryPolicy       RetryPolicy
+	deadLetterHandler DeadLetterHandler
+	breaker           *circuitBreaker
+	metrics           MetricsSink
 }
 
 // DefaultTransportConfiguration - the default fields used by the transport configuration
@@ -64,6 +76,23 @@ type DefaultTransportConfiguration struct {
 	BatchSendInterval    time.Duration
 	RequestTimeout       time.Duration
 	SerializerBufferSize int
+
+	// MaxBatchSize - flushes the current batch as soon as it reaches this number of points,
+	// instead of waiting for BatchSendInterval. Zero disables the size trigger.
+	MaxBatchSize int
+
+	// MaxBatchBytes - flushes the current batch as soon as its estimated size (in bytes) reaches
+	// this threshold, instead of waiting for BatchSendInterval. Zero disables the bytes trigger.
+	MaxBatchBytes int
+
+	// RetryPolicy - retry/backoff/circuit breaker behaviour applied to failed batch transfers
+	RetryPolicy RetryPolicy
+
+	// DeadLetterHandler - invoked with batches that could not be delivered, if informed
+	DeadLetterHandler DeadLetterHandler
+
+	// Metrics - receives batch loop observability events. A no-op sink is used if not informed.
+	Metrics MetricsSink
 }
 
 // Validate - validates the default itens from the configuration
@@ -99,11 +128,26 @@ func (t *transportCore) Start() error {
 
 	t.logger.Info("starting transport...", lf...)
 
+	if t.closeChan == nil {
+		t.closeChan = make(chan struct{})
+	}
+
 	go t.transferDataLoop()
 
 	return nil
 }
 
+// estimatePointSize - gives a rough byte size estimate for a data channel item, used only
+// to decide whether the batch's size threshold was reached
+func estimatePointSize(point interface{}) int {
+
+	if s, ok := point.(fmt.Stringer); ok {
+		return len(s.String())
+	}
+
+	return len(fmt.Sprintf("%v", point))
+}
+
 // transferDataLoop - transfers the data to the backend throught this transport
 func (t *transportCore) transferDataLoop() {
 
@@ -115,54 +159,113 @@ func (t *transportCore) transferDataLoop() {
 
 	t.logger.Info("initializing transfer data loop...", lf...)
 
+	flushTimer := time.NewTimer(t.batchSendInterval)
+	defer flushTimer.Stop()
+
+	sink := t.metricsSink()
+
+	points := []interface{}{}
+	batchBytes := 0
+
+	flush := func() {
+
+		numPoints := len(points)
+		if numPoints == 0 {
+			t.logger.Info("buffer is empty, no data will be send", lf...)
+			return
+		}
+
+		t.logger.Info(fmt.Sprintf("sending a batch of %d points...", numPoints), lf...)
+
+		sink.ObserveHistogram("timeline.batch.size", nil, float64(numPoints))
+
+		t.sendBatch(points, lf)
+
+		points = []interface{}{}
+		batchBytes = 0
+	}
+
 outterFor:
 	for {
-		<-time.After(t.batchSendInterval)
+		select {
+		case point, ok := <-t.pointChannel:
 
-		if t.dataTransferInProgress > 0 {
-			t.logger.Info("another data transfer is in progress, skipping...", lf...)
-			continue
-		}
+			if !ok {
+				t.logger.Info("breaking data transfer loop", lf...)
+				flush()
+				break outterFor
+			}
+
+			points = append(points, point)
 
-		go atomic.SwapUint32(&t.dataTransferInProgress, 1)
+			if t.maxBatchBytes > 0 {
+				batchBytes += estimatePointSize(point)
+			}
 
-		points := []interface{}{}
-		numPoints := 0
+			if (t.maxBatchSize > 0 && len(points) >= t.maxBatchSize) ||
+				(t.maxBatchBytes > 0 && batchBytes >= t.maxBatchBytes) {
 
-	innerLoop:
-		for {
-			select {
-			case point, ok := <-t.pointChannel:
+				flush()
 
-				if !ok {
-					t.logger.Info("breaking data transfer loop", lf...)
-					break outterFor
+				if !flushTimer.Stop() {
+					<-flushTimer.C
 				}
+				flushTimer.Reset(t.batchSendInterval)
+			}
 
-				points = append(points, point)
+		case <-flushTimer.C:
 
-			default:
-				break innerLoop
-			}
+			sink.ObserveHistogram("timeline.channel.occupancy", nil, float64(len(t.pointChannel)))
+
+			flush()
+			flushTimer.Reset(t.batchSendInterval)
 		}
+	}
+}
 
-		numPoints = len(points)
+// DataChannel - returns the channel used to feed points into this transport
+func (t *transportCore) DataChannel() chan<- interface{} {
 
-		if numPoints == 0 {
-			t.logger.Info("buffer is empty, no data will be send", lf...)
-			continue
-		}
+	return t.pointChannel
+}
 
-		t.logger.Info(fmt.Sprintf("sending a batch of %d points...", numPoints), lf...)
+// ErrSendTimeout - returned by SendWithDeadline when the deadline elapses before the item
+// could be queued
+type ErrSendTimeout struct {
+	Deadline time.Time
+}
 
-		err := t.transport.TransferData(points)
-		if err != nil {
-			t.logger.Error(err.Error(), lf...)
-		} else {
-			t.logger.Info(fmt.Sprintf("batch of %d points were sent!", numPoints), lf...)
-		}
+// Error - implements the error interface
+func (e *ErrSendTimeout) Error() string {
+
+	return fmt.Sprintf("send deadline exceeded at %s", e.Deadline.Format(time.RFC3339))
+}
+
+// SendWithDeadline - sends an item to the data channel, giving up with an ErrSendTimeout if the
+// deadline elapses before the channel has room, or an error if the transport is closed first
+func (t *transportCore) SendWithDeadline(item interface{}, deadline time.Time) error {
+
+	// Close also closes the data channel, so a send must not be attempted once it was called
+	select {
+	case <-t.closeChan:
+		return errors.New("transport is closed")
+	default:
+	}
 
-		go atomic.SwapUint32(&t.dataTransferInProgress, 0)
+	deadlineCh := make(chan struct{})
+	timer := time.AfterFunc(time.Until(deadline), func() {
+		close(deadlineCh)
+	})
+	defer timer.Stop()
+
+	select {
+	case t.pointChannel <- item:
+		return nil
+	case <-deadlineCh:
+		t.metricsSink().IncCounter("timeline.send.deadline_exceeded", nil, 1)
+		return &ErrSendTimeout{Deadline: deadline}
+	case <-t.closeChan:
+		return errors.New("transport is closed")
 	}
 }
 
@@ -177,5 +280,6 @@ func (t *transportCore) Close() {
 
 	t.logger.Info("closing...", lf...)
 
+	close(t.closeChan)
 	close(t.pointChannel)
 }